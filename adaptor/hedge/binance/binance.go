@@ -0,0 +1,139 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package binance implements business.HedgeSource against the Binance REST
+// API.
+package binance
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/awishformore/m3/business"
+)
+
+const baseURL = "https://api.binance.com"
+
+// priceScale is the fixed-point scale applied to prices reported by
+// BestBidAsk, so sub-1.0 prices don't get truncated away by converting
+// straight to a big.Int.
+var priceScale = big.NewFloat(1e8)
+
+// Source is a business.HedgeSource backed by the Binance REST API.
+type Source struct {
+	client *http.Client
+	key    string
+	secret string
+}
+
+// New creates a new Binance hedge source authenticated with the given API
+// key and secret.
+func New(key string, secret string) *Source {
+	return &Source{
+		client: &http.Client{Timeout: 5 * time.Second},
+		key:    key,
+		secret: secret,
+	}
+}
+
+type bookTicker struct {
+	BidPrice string `json:"bidPrice"`
+	AskPrice string `json:"askPrice"`
+}
+
+// BestBidAsk implements business.HedgeSource.
+func (s *Source) BestBidAsk(pair string) (*big.Int, *big.Int, error) {
+
+	res, err := s.client.Get(fmt.Sprintf("%s/api/v3/ticker/bookTicker?symbol=%s", baseURL, pair))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not fetch book ticker: %v", err)
+	}
+	defer res.Body.Close()
+
+	var ticker bookTicker
+	err = json.NewDecoder(res.Body).Decode(&ticker)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decode book ticker: %v", err)
+	}
+
+	bid, ok := new(big.Float).SetString(ticker.BidPrice)
+	if !ok {
+		return nil, nil, fmt.Errorf("could not parse bid price: %v", ticker.BidPrice)
+	}
+	ask, ok := new(big.Float).SetString(ticker.AskPrice)
+	if !ok {
+		return nil, nil, fmt.Errorf("could not parse ask price: %v", ticker.AskPrice)
+	}
+
+	bidScaled, _ := new(big.Float).Mul(bid, priceScale).Int(nil)
+	askScaled, _ := new(big.Float).Mul(ask, priceScale).Int(nil)
+
+	return bidScaled, askScaled, nil
+}
+
+// Hedge implements business.HedgeSource by placing a market order on Binance
+// to offset a fill taken on-chain.
+func (s *Source) Hedge(side business.Side, pair string, amount *big.Int) error {
+
+	action := "SELL"
+	if side == business.Buy {
+		action = "BUY"
+	}
+
+	values := url.Values{}
+	values.Set("symbol", pair)
+	values.Set("side", action)
+	values.Set("type", "MARKET")
+	values.Set("quantity", new(big.Float).SetInt(amount).Text('f', -1))
+	values.Set("timestamp", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+	values.Set("signature", s.sign(values.Encode()))
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/api/v3/order", nil)
+	if err != nil {
+		return fmt.Errorf("could not build order request: %v", err)
+	}
+	req.URL.RawQuery = values.Encode()
+	req.Header.Set("X-MBX-APIKEY", s.key)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not execute order request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("order request failed with status %v", res.StatusCode)
+	}
+
+	return nil
+}
+
+// sign computes the HMAC-SHA256 signature Binance requires on every signed
+// request, over the already-encoded query string.
+func (s *Source) sign(query string) string {
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte(query))
+	return hex.EncodeToString(mac.Sum(nil))
+}
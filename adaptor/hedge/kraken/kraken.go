@@ -0,0 +1,172 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package kraken implements business.HedgeSource against the Kraken REST
+// API.
+package kraken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awishformore/m3/business"
+)
+
+const baseURL = "https://api.kraken.com"
+
+// priceScale is the fixed-point scale applied to prices reported by
+// BestBidAsk, so sub-1.0 prices don't get truncated away by converting
+// straight to a big.Int.
+var priceScale = big.NewFloat(1e8)
+
+// Source is a business.HedgeSource backed by the Kraken REST API.
+type Source struct {
+	client *http.Client
+	key    string
+	secret string
+}
+
+// New creates a new Kraken hedge source authenticated with the given API
+// key and secret.
+func New(key string, secret string) *Source {
+	return &Source{
+		client: &http.Client{Timeout: 5 * time.Second},
+		key:    key,
+		secret: secret,
+	}
+}
+
+type tickerResponse struct {
+	Error  []string                    `json:"error"`
+	Result map[string]tickerPairResult `json:"result"`
+}
+
+type tickerPairResult struct {
+	Ask []string `json:"a"`
+	Bid []string `json:"b"`
+}
+
+// BestBidAsk implements business.HedgeSource.
+func (s *Source) BestBidAsk(pair string) (*big.Int, *big.Int, error) {
+
+	res, err := s.client.Get(fmt.Sprintf("%s/0/public/Ticker?pair=%s", baseURL, pair))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not fetch ticker: %v", err)
+	}
+	defer res.Body.Close()
+
+	var ticker tickerResponse
+	err = json.NewDecoder(res.Body).Decode(&ticker)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decode ticker: %v", err)
+	}
+	if len(ticker.Error) > 0 {
+		return nil, nil, fmt.Errorf("kraken returned error: %v", ticker.Error)
+	}
+
+	result, ok := ticker.Result[pair]
+	if !ok || len(result.Bid) == 0 || len(result.Ask) == 0 {
+		return nil, nil, fmt.Errorf("no ticker result for pair: %v", pair)
+	}
+
+	bid, ok := new(big.Float).SetString(result.Bid[0])
+	if !ok {
+		return nil, nil, fmt.Errorf("could not parse bid price: %v", result.Bid[0])
+	}
+	ask, ok := new(big.Float).SetString(result.Ask[0])
+	if !ok {
+		return nil, nil, fmt.Errorf("could not parse ask price: %v", result.Ask[0])
+	}
+
+	bidScaled, _ := new(big.Float).Mul(bid, priceScale).Int(nil)
+	askScaled, _ := new(big.Float).Mul(ask, priceScale).Int(nil)
+
+	return bidScaled, askScaled, nil
+}
+
+// Hedge implements business.HedgeSource by placing a market order on Kraken
+// to offset a fill taken on-chain.
+func (s *Source) Hedge(side business.Side, pair string, amount *big.Int) error {
+
+	action := "sell"
+	if side == business.Buy {
+		action = "buy"
+	}
+
+	path := "/0/private/AddOrder"
+
+	values := url.Values{}
+	values.Set("nonce", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+	values.Set("pair", pair)
+	values.Set("type", action)
+	values.Set("ordertype", "market")
+	values.Set("volume", new(big.Float).SetInt(amount).Text('f', -1))
+
+	signature, err := s.sign(path, values)
+	if err != nil {
+		return fmt.Errorf("could not sign order request: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+path, strings.NewReader(values.Encode()))
+	if err != nil {
+		return fmt.Errorf("could not build order request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("API-Key", s.key)
+	req.Header.Set("API-Sign", signature)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not execute order request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("order request failed with status %v", res.StatusCode)
+	}
+
+	return nil
+}
+
+// sign computes the HMAC-SHA512 signature Kraken requires on every private
+// request, over the SHA256 hash of the nonce and post data, keyed with the
+// base64-decoded API secret.
+func (s *Source) sign(path string, values url.Values) (string, error) {
+
+	secret, err := base64.StdEncoding.DecodeString(s.secret)
+	if err != nil {
+		return "", fmt.Errorf("could not decode API secret: %v", err)
+	}
+
+	hash := sha256.Sum256([]byte(values.Get("nonce") + values.Encode()))
+
+	mac := hmac.New(sha512.New, secret)
+	mac.Write([]byte(path))
+	mac.Write(hash[:])
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
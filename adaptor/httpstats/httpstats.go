@@ -0,0 +1,101 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package httpstats exposes a ProfitStats snapshot over HTTP, both as a
+// plain JSON document and as a Slack-compatible message attachment.
+package httpstats
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/awishformore/m3/business"
+)
+
+// Handler serves profit statistics snapshots.
+type Handler struct {
+	stats *business.ProfitStats
+}
+
+// NewHandler creates a new Handler for the given ProfitStats.
+func NewHandler(stats *business.ProfitStats) *Handler {
+	return &Handler{stats: stats}
+}
+
+// ServeHTTP implements http.Handler. It returns the raw JSON snapshot by
+// default, or a Slack-compatible attachment if the "format=slack" query
+// parameter is set.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	snapshot := h.stats.Snapshot()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.URL.Query().Get("format") == "slack" {
+		err := json.NewEncoder(w).Encode(slackAttachment(snapshot))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err := json.NewEncoder(w).Encode(snapshot)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// slackMessage is a minimal Slack incoming-webhook payload with a single
+// attachment summarizing the snapshot.
+type slackMessage struct {
+	Attachments []slackAttachmentFields `json:"attachments"`
+}
+
+type slackAttachmentFields struct {
+	Title  string       `json:"title"`
+	Fields []slackField `json:"fields"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// slackAttachment turns a profit snapshot into a Slack-compatible message
+// with one field per tracked pair.
+func slackAttachment(snapshot business.ProfitSnapshot) slackMessage {
+
+	fields := make([]slackField, 0, len(snapshot.Pairs))
+	for _, pair := range snapshot.Pairs {
+		fields = append(fields, slackField{
+			Title: fmt.Sprintf("%v/%v", pair.Base.Hex(), pair.Quote.Hex()),
+			Value: fmt.Sprintf("base: %v, quote: %v, volume: %v", pair.TotalBaseProfit, pair.TotalQuoteProfit, pair.Volume),
+			Short: false,
+		})
+	}
+
+	return slackMessage{
+		Attachments: []slackAttachmentFields{
+			{
+				Title:  fmt.Sprintf("m3 profit stats since %v", snapshot.Since),
+				Fields: fields,
+			},
+		},
+	}
+}
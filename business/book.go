@@ -0,0 +1,69 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/awishformore/m3/model"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Book represents the resting bids and asks for one token pair on the Maker
+// Market contract.
+type Book struct {
+	Base  common.Address
+	Quote common.Address
+	bids  []*model.Order
+	asks  []*model.Order
+}
+
+// AddBid adds a bid to the book, keeping bids sorted from highest to lowest
+// rate.
+func (b *Book) AddBid(order *model.Order) {
+	b.bids = append(b.bids, order)
+	sort.Slice(b.bids, func(i, j int) bool {
+		return b.bids[i].Rate().Cmp(b.bids[j].Rate()) > 0
+	})
+}
+
+// AddAsk adds an ask to the book, keeping asks sorted from lowest to highest
+// rate.
+func (b *Book) AddAsk(order *model.Order) {
+	b.asks = append(b.asks, order)
+	sort.Slice(b.asks, func(i, j int) bool {
+		return b.asks[i].Rate().Cmp(b.asks[j].Rate()) < 0
+	})
+}
+
+// HighestBid returns the best (highest rate) resting bid.
+func (b *Book) HighestBid() (*model.Order, error) {
+	if len(b.bids) == 0 {
+		return nil, fmt.Errorf("no bids in book")
+	}
+	return b.bids[0], nil
+}
+
+// LowestAsk returns the best (lowest rate) resting ask.
+func (b *Book) LowestAsk() (*model.Order, error) {
+	if len(b.asks) == 0 {
+		return nil, fmt.Errorf("no asks in book")
+	}
+	return b.asks[0], nil
+}
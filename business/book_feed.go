@@ -0,0 +1,229 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/awishformore/m3/model"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EventKind identifies which kind of order book event occurred.
+type EventKind int
+
+const (
+	// EventMake signals that a new order started resting on the book.
+	EventMake EventKind = iota
+	// EventTake signals that a resting order was filled, fully or partially.
+	EventTake
+	// EventKill signals that a resting order was cancelled.
+	EventKill
+)
+
+// OrderEvent is a single LogMake, LogTake or LogKill event on the Maker
+// Market contract.
+type OrderEvent struct {
+	Kind   EventKind
+	Order  *model.Order
+	Amount *big.Int // amount taken, only set for EventTake
+}
+
+// ApplyEvent updates the book in place to reflect a LogMake, LogTake or
+// LogKill event, handling partial fills and cancellations.
+func (b *Book) ApplyEvent(evt OrderEvent) {
+	switch evt.Kind {
+	case EventMake:
+		if evt.Order.BuyToken == b.Base && evt.Order.SellToken == b.Quote {
+			b.AddBid(evt.Order)
+		} else {
+			b.AddAsk(evt.Order)
+		}
+	case EventTake:
+		b.bids = applyTake(b.bids, evt.Order.ID, evt.Amount)
+		b.asks = applyTake(b.asks, evt.Order.ID, evt.Amount)
+	case EventKill:
+		b.bids = applyKill(b.bids, evt.Order.ID)
+		b.asks = applyKill(b.asks, evt.Order.ID)
+	}
+}
+
+// applyTake reduces the matching order by amount of its sell token, scaling
+// its buy amount down by the same ratio, and drops it once fully filled.
+func applyTake(orders []*model.Order, id *big.Int, amount *big.Int) []*model.Order {
+	kept := orders[:0]
+	for _, order := range orders {
+		if order.ID.Cmp(id) != 0 {
+			kept = append(kept, order)
+			continue
+		}
+
+		buyReduction := new(big.Int).Mul(amount, order.BuyAmount)
+		buyReduction.Div(buyReduction, order.SellAmount)
+
+		order.SellAmount.Sub(order.SellAmount, amount)
+		order.BuyAmount.Sub(order.BuyAmount, buyReduction)
+
+		if order.SellAmount.Sign() > 0 {
+			kept = append(kept, order)
+		}
+	}
+	return kept
+}
+
+// applyKill drops the matching order from the slice.
+func applyKill(orders []*model.Order, id *big.Int) []*model.Order {
+	kept := orders[:0]
+	for _, order := range orders {
+		if order.ID.Cmp(id) != 0 {
+			kept = append(kept, order)
+		}
+	}
+	return kept
+}
+
+// BookFeed maintains an incremental in-memory Book per pair, fed by a
+// streaming subscription to the Maker Market contract's order events where
+// available, and falling back to polling Orders() otherwise.
+type BookFeed struct {
+	log     Logger
+	market  Market
+	refresh time.Duration
+	books   map[string]*Book
+	updates chan []*Book
+}
+
+// NewBookFeed creates a new book feed against the given market, using
+// refresh as the polling interval if event subscriptions aren't supported.
+func NewBookFeed(log Logger, market Market, refresh time.Duration) *BookFeed {
+	return &BookFeed{
+		log:     log,
+		market:  market,
+		refresh: refresh,
+		books:   make(map[string]*Book),
+		updates: make(chan []*Book, 1),
+	}
+}
+
+// Start begins streaming book updates until ctx is cancelled, returning a
+// channel that receives the full set of books every time one of them
+// changes.
+func (bf *BookFeed) Start(ctx context.Context) (<-chan []*Book, error) {
+	events, err := bf.market.SubscribeOrders(ctx)
+	if err != nil {
+		bf.log.Warningf("event subscription unavailable, falling back to polling orders (%v)", err)
+		go bf.poll(ctx)
+		return bf.updates, nil
+	}
+
+	orders, err := bf.market.Orders()
+	if err != nil {
+		return nil, fmt.Errorf("could not seed books from resting orders: %v", err)
+	}
+	bf.rebuild(orders)
+	bf.publish()
+
+	go bf.stream(ctx, events)
+	return bf.updates, nil
+}
+
+// stream consumes order events and applies them to the relevant book.
+func (bf *BookFeed) stream(ctx context.Context, events <-chan OrderEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt, ok := <-events:
+			if !ok {
+				return
+			}
+			book := bf.bookFor(evt.Order.BuyToken, evt.Order.SellToken)
+			book.ApplyEvent(evt)
+			bf.publish()
+		}
+	}
+}
+
+// poll falls back to refreshing the full set of books on a timer, for
+// backends that don't support event filters.
+func (bf *BookFeed) poll(ctx context.Context) {
+	ticker := time.NewTicker(bf.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			orders, err := bf.market.Orders()
+			if err != nil {
+				bf.log.Errorf("could not poll orders (%v)", err)
+				continue
+			}
+			bf.rebuild(orders)
+			bf.publish()
+		}
+	}
+}
+
+// bookFor returns the book for the pair of the two given tokens, in either
+// order, creating it if it doesn't exist yet.
+func (bf *BookFeed) bookFor(buy common.Address, sell common.Address) *Book {
+	bidPair := buy.Hex() + sell.Hex()
+	askPair := sell.Hex() + buy.Hex()
+
+	if book, ok := bf.books[bidPair]; ok {
+		return book
+	}
+	if book, ok := bf.books[askPair]; ok {
+		return book
+	}
+
+	book := &Book{Base: buy, Quote: sell}
+	bf.books[bidPair] = book
+	return book
+}
+
+// rebuild replaces the contents of all books from a fresh poll of the full
+// order set.
+func (bf *BookFeed) rebuild(orders []*model.Order) {
+	bf.books = make(map[string]*Book)
+	for _, order := range orders {
+		book := bf.bookFor(order.BuyToken, order.SellToken)
+		if order.BuyToken == book.Base {
+			book.AddBid(order)
+		} else {
+			book.AddAsk(order)
+		}
+	}
+}
+
+// publish pushes the current set of books onto the updates channel,
+// dropping the update if the consumer isn't keeping up.
+func (bf *BookFeed) publish() {
+	books := make([]*Book, 0, len(bf.books))
+	for _, book := range bf.books {
+		books = append(books, book)
+	}
+	select {
+	case bf.updates <- books:
+	default:
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"math"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerConfig holds the thresholds at which a CircuitBreaker trips
+// and halts further arbitrage.
+type CircuitBreakerConfig struct {
+
+	// MaximumConsecutiveLossTimes is the number of losing twins in a row
+	// that trips the breaker.
+	MaximumConsecutiveLossTimes int
+
+	// MaximumConsecutiveTotalLoss is the cumulative wei lost over Window
+	// that trips the breaker.
+	MaximumConsecutiveTotalLoss *big.Int
+
+	// MaximumLossPerRound is the maximum single-twin cost, after
+	// subtracting realised gains, that is tolerated before tripping.
+	MaximumLossPerRound *big.Int
+
+	// Window is the rolling window over which MaximumConsecutiveTotalLoss
+	// is evaluated.
+	Window time.Duration
+
+	// HaltDuration is how long the breaker stays tripped before it resets
+	// itself and allows arbitrage to resume.
+	HaltDuration time.Duration
+}
+
+// loss records a single losing twin for rolling window accounting.
+type loss struct {
+	at     time.Time
+	amount *big.Int
+}
+
+// CircuitBreakerStatus is a snapshot of a CircuitBreaker's internal state,
+// exposed mainly for tests.
+type CircuitBreakerStatus struct {
+	Tripped           bool
+	ConsecutiveLosses int
+	RollingLoss       *big.Int
+	HaltUntil         time.Time
+}
+
+// CircuitBreaker halts the Matcher execution loop when configured loss
+// thresholds are breached, giving the operator a cool-off period before
+// arbitrage resumes.
+type CircuitBreaker struct {
+	log    Logger
+	config CircuitBreakerConfig
+
+	mu                sync.Mutex
+	consecutiveLosses int
+	losses            []loss
+	tripped           bool
+	haltUntil         time.Time
+}
+
+// NewCircuitBreaker creates a new circuit breaker with the given thresholds.
+// A zero-value MaximumConsecutiveLossTimes, MaximumConsecutiveTotalLoss or
+// MaximumLossPerRound defaults to "never trips on this check" rather than
+// the zero value, which would trip the breaker on the very first loss (or,
+// for the *big.Int fields, panic the first time Record compares against a
+// nil pointer).
+func NewCircuitBreaker(log Logger, config CircuitBreakerConfig) *CircuitBreaker {
+	if config.MaximumConsecutiveLossTimes <= 0 {
+		config.MaximumConsecutiveLossTimes = math.MaxInt32
+	}
+	if config.MaximumConsecutiveTotalLoss == nil {
+		config.MaximumConsecutiveTotalLoss = new(big.Int).Lsh(big.NewInt(1), 255)
+	}
+	if config.MaximumLossPerRound == nil {
+		config.MaximumLossPerRound = new(big.Int).Lsh(big.NewInt(1), 255)
+	}
+	return &CircuitBreaker{
+		log:    log,
+		config: config,
+	}
+}
+
+// Record registers the net cost of an executed twin, in wei, after
+// subtracting realised gains. A positive cost is a loss; a zero or negative
+// cost is a gain or break-even trade. It trips the breaker if any of the
+// configured thresholds are breached.
+func (cb *CircuitBreaker) Record(cost *big.Int) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	zero := big.NewInt(0)
+
+	// a single twin losing more than we tolerate trips the breaker right away
+	if cost.Cmp(cb.config.MaximumLossPerRound) > 0 {
+		cb.trip("single-twin loss of %v wei exceeded maximum of %v wei", cost, cb.config.MaximumLossPerRound)
+		return
+	}
+
+	// a twin that didn't lose money resets the consecutive loss streak
+	if cost.Cmp(zero) <= 0 {
+		cb.consecutiveLosses = 0
+		return
+	}
+
+	// otherwise, track it as a loss for both the streak and rolling window
+	cb.consecutiveLosses++
+	cb.losses = append(cb.losses, loss{at: cb.now(), amount: new(big.Int).Set(cost)})
+	cb.prune()
+
+	if cb.consecutiveLosses >= cb.config.MaximumConsecutiveLossTimes {
+		cb.trip("hit %v consecutive losing twins", cb.consecutiveLosses)
+		return
+	}
+
+	rolling := cb.rollingLoss()
+	if rolling.Cmp(cb.config.MaximumConsecutiveTotalLoss) > 0 {
+		cb.trip("rolling loss of %v wei over %v exceeded maximum of %v wei", rolling, cb.config.Window, cb.config.MaximumConsecutiveTotalLoss)
+	}
+}
+
+// Trip manually trips the breaker, halting arbitrage for HaltDuration.
+func (cb *CircuitBreaker) Trip() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.trip("manually tripped")
+}
+
+// trip must be called with cb.mu held.
+func (cb *CircuitBreaker) trip(format string, args ...interface{}) {
+	cb.tripped = true
+	cb.haltUntil = cb.now().Add(cb.config.HaltDuration)
+	if cb.log != nil {
+		cb.log.Warningf("circuit breaker tripped: "+format, args...)
+	}
+}
+
+// Reset clears the tripped state and all loss accounting.
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.consecutiveLosses = 0
+	cb.losses = nil
+	cb.tripped = false
+	cb.haltUntil = time.Time{}
+}
+
+// Tripped reports whether the breaker currently halts arbitrage. If the
+// halt duration has elapsed since the breaker tripped, it resets itself and
+// returns false.
+func (cb *CircuitBreaker) Tripped() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !cb.tripped {
+		return false
+	}
+	if cb.now().Before(cb.haltUntil) {
+		return true
+	}
+
+	cb.consecutiveLosses = 0
+	cb.losses = nil
+	cb.tripped = false
+	cb.haltUntil = time.Time{}
+	return false
+}
+
+// Status returns a snapshot of the breaker's internal state, mainly for
+// tests.
+func (cb *CircuitBreaker) Status() CircuitBreakerStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return CircuitBreakerStatus{
+		Tripped:           cb.tripped,
+		ConsecutiveLosses: cb.consecutiveLosses,
+		RollingLoss:       cb.rollingLoss(),
+		HaltUntil:         cb.haltUntil,
+	}
+}
+
+// rollingLoss sums the losses still within the rolling window. Must be
+// called with cb.mu held.
+func (cb *CircuitBreaker) rollingLoss() *big.Int {
+	sum := big.NewInt(0)
+	for _, l := range cb.losses {
+		sum.Add(sum, l.amount)
+	}
+	return sum
+}
+
+// prune drops losses that have fallen outside the rolling window. Must be
+// called with cb.mu held.
+func (cb *CircuitBreaker) prune() {
+	cutoff := cb.now().Add(-cb.config.Window)
+	kept := cb.losses[:0]
+	for _, l := range cb.losses {
+		if l.at.After(cutoff) {
+			kept = append(kept, l)
+		}
+	}
+	cb.losses = kept
+}
+
+// now returns the current time, factored out as a method so it stays in one
+// place if we ever need to mock it in tests.
+func (cb *CircuitBreaker) now() time.Time {
+	return time.Now()
+}
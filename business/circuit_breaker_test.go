@@ -0,0 +1,107 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		MaximumConsecutiveLossTimes: 3,
+		MaximumConsecutiveTotalLoss: big.NewInt(100),
+		MaximumLossPerRound:         big.NewInt(1000),
+		Window:                      time.Minute,
+		HaltDuration:                time.Hour,
+	}
+}
+
+func TestCircuitBreakerTripsOnConsecutiveLosses(t *testing.T) {
+	cb := NewCircuitBreaker(nil, testConfig())
+
+	cb.Record(big.NewInt(1))
+	cb.Record(big.NewInt(1))
+	if cb.Tripped() {
+		t.Fatalf("breaker tripped before reaching the consecutive loss threshold")
+	}
+
+	cb.Record(big.NewInt(1))
+	if !cb.Tripped() {
+		t.Fatalf("breaker did not trip after %v consecutive losses", testConfig().MaximumConsecutiveLossTimes)
+	}
+}
+
+func TestCircuitBreakerTripsOnSingleLoss(t *testing.T) {
+	cb := NewCircuitBreaker(nil, testConfig())
+
+	cb.Record(big.NewInt(1001))
+	if !cb.Tripped() {
+		t.Fatalf("breaker did not trip on a single-twin loss exceeding the maximum")
+	}
+}
+
+func TestCircuitBreakerGainResetsConsecutiveLosses(t *testing.T) {
+	cb := NewCircuitBreaker(nil, testConfig())
+
+	cb.Record(big.NewInt(1))
+	cb.Record(big.NewInt(1))
+	cb.Record(big.NewInt(-5))
+	cb.Record(big.NewInt(1))
+	cb.Record(big.NewInt(1))
+
+	if cb.Tripped() {
+		t.Fatalf("breaker tripped even though a gain reset the consecutive loss streak")
+	}
+	if status := cb.Status(); status.ConsecutiveLosses != 2 {
+		t.Fatalf("expected 2 consecutive losses after reset, got %v", status.ConsecutiveLosses)
+	}
+}
+
+func TestCircuitBreakerZeroConsecutiveLossTimesDisablesCheck(t *testing.T) {
+	config := testConfig()
+	config.MaximumConsecutiveLossTimes = 0
+	cb := NewCircuitBreaker(nil, config)
+
+	for i := 0; i < 10; i++ {
+		cb.Record(big.NewInt(1))
+	}
+
+	if cb.Tripped() {
+		t.Fatalf("breaker tripped on consecutive losses even though the check was disabled")
+	}
+}
+
+func TestCircuitBreakerManualTripAndReset(t *testing.T) {
+	cb := NewCircuitBreaker(nil, testConfig())
+
+	cb.Trip()
+	if !cb.Tripped() {
+		t.Fatalf("breaker did not report tripped after a manual Trip")
+	}
+
+	cb.Reset()
+	if cb.Tripped() {
+		t.Fatalf("breaker still tripped after Reset")
+	}
+	status := cb.Status()
+	if status.Tripped || status.ConsecutiveLosses != 0 {
+		t.Fatalf("expected clean status after Reset, got %+v", status)
+	}
+}
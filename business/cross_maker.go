@@ -0,0 +1,276 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/awishformore/m3/model"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CrossMaker is a market making strategy that quotes orders onto the Maker
+// Market contract and immediately hedges filled positions on a configurable
+// centralized exchange, locking in the quoted spread.
+type CrossMaker struct {
+	log       Logger
+	market    Market
+	quoter    *Quoter
+	hedge     HedgeSource
+	base      common.Address
+	quote     common.Address
+	pair      string
+	spread    *big.Int
+	tolerance *big.Int
+	size      *big.Int
+	refresh   time.Duration
+	fromBlock uint64
+	bidID     *big.Int
+	askID     *big.Int
+	bidRate   *big.Int
+	askRate   *big.Int
+	done      chan struct{}
+}
+
+// NewCrossMaker creates a new cross-exchange hedged market maker for the
+// given pair, quoting onto market through quoter and hedging fills on hedge.
+func NewCrossMaker(log Logger, market Market, quoter *Quoter, hedge HedgeSource, base common.Address, quote common.Address, pair string, options ...func(*CrossMaker)) *CrossMaker {
+
+	// create the cross maker with sane defaults
+	cm := CrossMaker{
+		log:       log,
+		market:    market,
+		quoter:    quoter,
+		hedge:     hedge,
+		base:      base,
+		quote:     quote,
+		pair:      pair,
+		spread:    big.NewInt(10),
+		tolerance: big.NewInt(5),
+		size:      big.NewInt(1),
+		refresh:   time.Minute,
+		done:      make(chan struct{}),
+	}
+
+	// apply the optional parameters
+	for _, option := range options {
+		option(&cm)
+	}
+
+	// start the execution loop
+	go cm.start()
+
+	return &cm
+}
+
+// SetCrossSpread allows specifying the spread (in pips) applied to either
+// side of the reference mid-price when quoting.
+func SetCrossSpread(spread uint64) func(*CrossMaker) {
+	return func(cm *CrossMaker) {
+		cm.spread.SetUint64(spread)
+	}
+}
+
+// SetCrossTolerance allows specifying how far (in pips) the reference
+// mid-price has to move before resting quotes are cancelled and reposted.
+func SetCrossTolerance(tolerance uint64) func(*CrossMaker) {
+	return func(cm *CrossMaker) {
+		cm.tolerance.SetUint64(tolerance)
+	}
+}
+
+// SetCrossRefresh allows specifying a custom refresh interval for the
+// reference price check.
+func SetCrossRefresh(refresh time.Duration) func(*CrossMaker) {
+	return func(cm *CrossMaker) {
+		cm.refresh = refresh
+	}
+}
+
+// SetCrossSize allows specifying the size, in base tokens, quoted on each
+// side of the book.
+func SetCrossSize(size *big.Int) func(*CrossMaker) {
+	return func(cm *CrossMaker) {
+		cm.size = size
+	}
+}
+
+// SetCrossFromBlock allows specifying the block from which to subscribe to
+// fills, so that start() doesn't replay the whole chain's history of fills
+// on every process start. Callers should pass the current chain head.
+func SetCrossFromBlock(fromBlock uint64) func(*CrossMaker) {
+	return func(cm *CrossMaker) {
+		cm.fromBlock = fromBlock
+	}
+}
+
+// start will begin the execution loop of the cross maker.
+func (cm *CrossMaker) start() {
+
+	// initialize ticker for reference price checks
+	ticker := time.NewTicker(cm.refresh)
+
+	// subscribe to fills on our own orders so we can hedge them immediately
+	fills, err := cm.market.Fills(cm.fromBlock)
+	if err != nil {
+		cm.log.Criticalf("could not subscribe to fills (%v)", err)
+		close(cm.done)
+		return
+	}
+
+	// run the execution loop until it quits
+	cm.run(cm.done, ticker.C, fills)
+
+	// close channels and clean up
+	ticker.Stop()
+	close(cm.done)
+}
+
+// Stop will end the execution loop of the cross maker and return after
+// cleanly shutting down.
+func (cm *CrossMaker) Stop() {
+	cm.done <- struct{}{}
+	<-cm.done
+}
+
+// run will run the cross maker execution loop, refreshing quotes on every
+// tick and hedging fills as soon as they are detected.
+func (cm *CrossMaker) run(done <-chan struct{}, refresh <-chan time.Time, fills <-chan model.Fill) {
+Loop:
+	for {
+		select {
+
+		// we received the stop signal, so quit the execution loop
+		case <-done:
+			break Loop
+
+		// on every refresh interval, check the reference price and requote
+		// if it moved beyond our tolerance
+		case <-refresh:
+			err := cm.requote()
+			if err != nil {
+				cm.log.Errorf("could not requote (%v)", err)
+			}
+
+		// one of our resting orders was filled, so hedge it immediately on
+		// the centralized venue to lock in the spread
+		case fill := <-fills:
+			err := cm.settle(fill)
+			if err != nil {
+				cm.log.Errorf("could not hedge fill (%v)", err)
+			}
+		}
+	}
+}
+
+// requote pulls the current reference mid-price from the hedge source and,
+// if it has moved beyond the configured tolerance, cancels the existing
+// quotes and reposts them around the new mid-price.
+func (cm *CrossMaker) requote() error {
+
+	// get the current reference bid/ask from the hedge source
+	bid, ask, err := cm.hedge.BestBidAsk(cm.pair)
+	if err != nil {
+		return err
+	}
+
+	// apply our spread to either side of the reference price
+	bidRate := new(big.Int).Sub(bid, cm.spread)
+	askRate := new(big.Int).Add(ask, cm.spread)
+
+	// if we already have both quotes resting and the reference hasn't moved
+	// beyond our tolerance, leave them alone; gating on bidID/askID rather
+	// than just the rates means a leg that failed to (re)place on a prior
+	// requote is always retried below instead of being skipped because its
+	// now-stale rate happens to still be within tolerance of the reference
+	if cm.bidID != nil && cm.askID != nil {
+		bidMove := new(big.Int).Sub(cm.bidRate, bidRate)
+		bidMove.Abs(bidMove)
+		askMove := new(big.Int).Sub(cm.askRate, askRate)
+		askMove.Abs(askMove)
+		if bidMove.Cmp(cm.tolerance) <= 0 && askMove.Cmp(cm.tolerance) <= 0 {
+			return nil
+		}
+	}
+
+	// cancel the existing quotes, if any, and forget their IDs immediately
+	// so a failure below never leaves us tracking an already-cancelled order
+	if cm.bidID != nil {
+		err = cm.quoter.Cancel(cm.bidID)
+		if err != nil {
+			return err
+		}
+		cm.bidID = nil
+	}
+	if cm.askID != nil {
+		err = cm.quoter.Cancel(cm.askID)
+		if err != nil {
+			return err
+		}
+		cm.askID = nil
+	}
+
+	// repost quotes on both sides of the new reference price, sizing each
+	// leg so the quote amount reflects size base tokens at that rate
+	bidQuote := new(big.Int).Mul(bidRate, cm.size)
+	askQuote := new(big.Int).Mul(askRate, cm.size)
+
+	// save the bid ID as soon as it's placed: if the ask leg below fails,
+	// the bid is still resting on-chain and must stay tracked so it can be
+	// cancelled on the next requote instead of being leaked
+	bidID, err := cm.quoter.Place(cm.quote, bidQuote, cm.base, cm.size)
+	if err != nil {
+		return err
+	}
+	cm.bidID = bidID
+	cm.bidRate = bidRate
+
+	askID, err := cm.quoter.Place(cm.base, cm.size, cm.quote, askQuote)
+	if err != nil {
+		return err
+	}
+	cm.askID = askID
+	cm.askRate = askRate
+
+	return nil
+}
+
+// settle reacts to a fill of one of our resting orders by firing the
+// opposite trade on the hedge venue, locking in the quoted spread. Fills
+// that don't match either of our own resting order IDs are ignored, since
+// Market.Fills streams every fill on the market, not just ours.
+func (cm *CrossMaker) settle(fill model.Fill) error {
+
+	cm.fromBlock = fill.Block + 1
+
+	// a fill on our bid means we bought base, so we sell it on the hedge
+	// venue; a fill on our ask means we sold base, so we buy it back
+	var side Side
+	switch {
+	case cm.bidID != nil && fill.OrderID.Cmp(cm.bidID) == 0:
+		side = Sell
+	case cm.askID != nil && fill.OrderID.Cmp(cm.askID) == 0:
+		side = Buy
+	default:
+		cm.log.Warningf("ignoring fill for order %v, not one of our own quotes", fill.OrderID)
+		return nil
+	}
+
+	return cm.hedge.Hedge(side, cm.pair, fill.Amount)
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/awishformore/m3/contract"
+	"github.com/awishformore/m3/model"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Atomic is the balance-checking capability that strategies such as
+// Matcher and Grid rely on to make sure they never overcommit inventory.
+type Atomic interface {
+	Balance(token common.Address) (*big.Int, error)
+}
+
+// Wallet extends Atomic with the ability to execute two opposing taker
+// trades against the Maker Market in a single atomic transaction.
+type Wallet interface {
+	Atomic
+	ExecuteAtomic(market Market, first *model.Order, firstSelling *big.Int, second *model.Order, secondSelling *big.Int) (*big.Int, error)
+}
+
+// EthBackend is the subset of a go-ethereum node connection that EthWallet
+// needs: binding and calling contracts, signing and broadcasting
+// transactions, and reading plain ETH balances.
+type EthBackend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// ethAddress is the pseudo-address used to mean plain ETH rather than an
+// ERC-20 token, following the convention of treating it as the zero
+// address since it has no contract to call balanceOf on.
+var ethAddress = common.Address{}
+
+// maxApproval is the amount approved on a token's first use, so repeated
+// trades of the same token don't need a fresh approval transaction every
+// time.
+var maxApproval = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// EthWallet is a Wallet backed by a keystore-loaded account. It calls
+// balanceOf on ERC-20 tokens (falling back to BalanceAt for plain ETH),
+// lazily approves the Maker Market to pull each token the first time it is
+// traded, and executes atomic twin trades through a small Atomic contract
+// wrapper that takes both legs in one transaction.
+type EthWallet struct {
+	log      Logger
+	backend  EthBackend
+	keystore *keystore.KeyStore
+	account  accounts.Account
+	chainID  *big.Int
+	atomic   *contract.Atomic
+	market   common.Address
+
+	mu         sync.Mutex
+	nonce      uint64
+	pending    map[uint64]common.Hash
+	tokens     map[common.Address]*contract.Token
+	allowances map[common.Address]*big.Int
+}
+
+// NewEthWallet creates a new EthWallet for account, unlocking it in ks with
+// passphrase, and binds the Atomic contract wrapper at atomicAddress used
+// to take both legs of a twin trade in one transaction against the Maker
+// Market at market.
+func NewEthWallet(log Logger, backend EthBackend, ks *keystore.KeyStore, account accounts.Account, passphrase string, market common.Address, atomicAddress common.Address, chainID *big.Int) (*EthWallet, error) {
+
+	err := ks.Unlock(account, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("could not unlock account %v: %v", account.Address, err)
+	}
+
+	atomic, err := contract.NewAtomic(atomicAddress, backend)
+	if err != nil {
+		return nil, fmt.Errorf("could not bind atomic contract: %v", err)
+	}
+
+	nonce, err := backend.PendingNonceAt(context.Background(), account.Address)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch starting nonce: %v", err)
+	}
+
+	w := EthWallet{
+		log:        log,
+		backend:    backend,
+		keystore:   ks,
+		account:    account,
+		chainID:    chainID,
+		atomic:     atomic,
+		market:     market,
+		nonce:      nonce,
+		pending:    make(map[uint64]common.Hash),
+		tokens:     make(map[common.Address]*contract.Token),
+		allowances: make(map[common.Address]*big.Int),
+	}
+
+	return &w, nil
+}
+
+// Balance implements Atomic. For the pseudo-address representing plain
+// ETH it reads the account balance directly; for any other address it
+// calls balanceOf on the ERC-20 contract at that address.
+func (w *EthWallet) Balance(token common.Address) (*big.Int, error) {
+
+	if token == ethAddress {
+		balance, err := w.backend.BalanceAt(context.Background(), w.account.Address, nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch ETH balance: %v", err)
+		}
+		return balance, nil
+	}
+
+	t, err := w.token(token)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := t.BalanceOf(&bind.CallOpts{}, w.account.Address)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch balance of %v: %v", token, err)
+	}
+
+	return balance, nil
+}
+
+// ExecuteAtomic implements Wallet. It makes sure both legs are approved
+// for the Maker Market, then constructs, signs and broadcasts a single
+// transaction through the Atomic contract wrapper that takes first and
+// then second, returning the gas cost of the transaction in wei.
+func (w *EthWallet) ExecuteAtomic(market Market, first *model.Order, firstSelling *big.Int, second *model.Order, secondSelling *big.Int) (*big.Int, error) {
+
+	err := w.ensureAllowance(first.SellToken, firstSelling)
+	if err != nil {
+		return nil, fmt.Errorf("could not approve first leg: %v", err)
+	}
+	err = w.ensureAllowance(second.SellToken, secondSelling)
+	if err != nil {
+		return nil, fmt.Errorf("could not approve second leg: %v", err)
+	}
+
+	nonce, opts := w.transactOpts()
+
+	tx, err := w.atomic.TakeTwo(opts, first.ID, firstSelling, second.ID, secondSelling)
+	if err != nil {
+		w.releaseNonce(nonce)
+		return nil, fmt.Errorf("could not submit atomic take: %v", err)
+	}
+	w.trackPending(nonce, tx)
+	defer w.clearPending(nonce)
+
+	receipt, err := bind.WaitMined(context.Background(), w.backend, tx)
+	if err != nil {
+		return nil, fmt.Errorf("could not confirm atomic take: %v", err)
+	}
+
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tx.GasPrice())
+
+	return cost, nil
+}
+
+// token returns the cached ERC-20 binding for the given address, binding
+// it for the first time if it hasn't been used yet.
+func (w *EthWallet) token(token common.Address) (*contract.Token, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if t, ok := w.tokens[token]; ok {
+		return t, nil
+	}
+
+	t, err := contract.NewToken(token, w.backend)
+	if err != nil {
+		return nil, fmt.Errorf("could not bind token %v: %v", token, err)
+	}
+	w.tokens[token] = t
+
+	return t, nil
+}
+
+// ensureAllowance makes sure at least amount of token is approved for the
+// Maker Market to pull on our behalf, reading the on-chain allowance once
+// per token and issuing a single approve transaction for maxApproval the
+// first time it turns out to be insufficient.
+func (w *EthWallet) ensureAllowance(token common.Address, amount *big.Int) error {
+
+	w.mu.Lock()
+	allowance, ok := w.allowances[token]
+	w.mu.Unlock()
+
+	if !ok {
+		t, err := w.token(token)
+		if err != nil {
+			return err
+		}
+		allowance, err = t.Allowance(&bind.CallOpts{}, w.account.Address, w.market)
+		if err != nil {
+			return fmt.Errorf("could not fetch allowance for %v: %v", token, err)
+		}
+		w.mu.Lock()
+		w.allowances[token] = allowance
+		w.mu.Unlock()
+	}
+
+	if allowance.Cmp(amount) >= 0 {
+		return nil
+	}
+
+	t, err := w.token(token)
+	if err != nil {
+		return err
+	}
+
+	nonce, opts := w.transactOpts()
+
+	_, err = t.Approve(opts, w.market, maxApproval)
+	if err != nil {
+		w.releaseNonce(nonce)
+		return fmt.Errorf("could not approve %v for market: %v", token, err)
+	}
+
+	w.mu.Lock()
+	w.allowances[token] = maxApproval
+	w.mu.Unlock()
+
+	return nil
+}
+
+// transactOpts reserves the next nonce and returns it together with the
+// signing options for a transaction from our account, so concurrent calls
+// into EthWallet never collide on the same nonce.
+func (w *EthWallet) transactOpts() (uint64, *bind.TransactOpts) {
+	w.mu.Lock()
+	nonce := w.nonce
+	w.nonce++
+	w.mu.Unlock()
+
+	opts := &bind.TransactOpts{
+		From:  w.account.Address,
+		Nonce: new(big.Int).SetUint64(nonce),
+		Value: big.NewInt(0),
+		Signer: func(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			return w.keystore.SignTx(w.account, tx, w.chainID)
+		},
+	}
+
+	return nonce, opts
+}
+
+// releaseNonce reclaims nonce after a transaction failed to submit. If
+// nothing else has claimed a later nonce since, it simply rolls the counter
+// back so the next transaction reuses it. Otherwise a later nonce is
+// already in flight, so rolling back would leave a gap that stalls every
+// transaction behind it forever; instead, close the gap by submitting a
+// zero-value no-op transaction at nonce.
+func (w *EthWallet) releaseNonce(nonce uint64) {
+	w.mu.Lock()
+	rollback := w.nonce == nonce+1
+	if rollback {
+		w.nonce = nonce
+	}
+	w.mu.Unlock()
+
+	if rollback {
+		return
+	}
+
+	err := w.fillNonceGap(nonce)
+	if err != nil {
+		w.log.Errorf("could not close nonce gap at %v: %v", nonce, err)
+	}
+}
+
+// fillNonceGap submits a zero-value self-transfer at nonce, so a failed
+// transaction whose nonce has already been passed by a later one doesn't
+// leave a permanent gap blocking every subsequent transaction.
+func (w *EthWallet) fillNonceGap(nonce uint64) error {
+
+	gasPrice, err := w.backend.SuggestGasPrice(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not suggest gas price: %v", err)
+	}
+
+	tx := types.NewTransaction(nonce, w.account.Address, big.NewInt(0), 21000, gasPrice, nil)
+
+	tx, err = w.keystore.SignTx(w.account, tx, w.chainID)
+	if err != nil {
+		return fmt.Errorf("could not sign gap-filling transaction: %v", err)
+	}
+
+	err = w.backend.SendTransaction(context.Background(), tx)
+	if err != nil {
+		return fmt.Errorf("could not broadcast gap-filling transaction: %v", err)
+	}
+
+	w.trackPending(nonce, tx)
+
+	return nil
+}
+
+// trackPending records the hash of a transaction submitted at nonce, so
+// outstanding transactions can be inspected while they're still mining.
+func (w *EthWallet) trackPending(nonce uint64, tx *types.Transaction) {
+	w.mu.Lock()
+	w.pending[nonce] = tx.Hash()
+	w.mu.Unlock()
+}
+
+// clearPending drops the pending-transaction entry for nonce once it has
+// been confirmed or given up on.
+func (w *EthWallet) clearPending(nonce uint64) {
+	w.mu.Lock()
+	delete(w.pending, nonce)
+	w.mu.Unlock()
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// FeeOracle converts a gas cost denominated in wei into an equivalent amount
+// of the given token, so fees can be tracked against whichever token a
+// strategy is trading.
+type FeeOracle interface {
+	Convert(wei *big.Int, token common.Address) (*big.Int, error)
+}
+
+// WeiFeeOracle is a FeeOracle that reports fees in wei directly, regardless
+// of the token, for setups that don't have a price feed for fee conversion.
+type WeiFeeOracle struct{}
+
+// Convert implements FeeOracle.
+func (WeiFeeOracle) Convert(wei *big.Int, token common.Address) (*big.Int, error) {
+	return wei, nil
+}
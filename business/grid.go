@@ -0,0 +1,483 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// GridSpacing selects how the price levels of a Grid are distributed
+// between its lower and upper bound.
+type GridSpacing int
+
+const (
+	// ArithmeticSpacing spaces levels evenly by absolute price difference.
+	ArithmeticSpacing GridSpacing = iota
+	// GeometricSpacing spaces levels evenly by price ratio, so the
+	// percentage gap between adjacent levels is constant.
+	GeometricSpacing
+)
+
+// gridLevel tracks the order currently resting at one price level of a
+// Grid.
+type gridLevel struct {
+	price     *big.Int
+	side      Side
+	id        *big.Int
+	base      *big.Int
+	quote     *big.Int
+	remaining *big.Int // sell amount still resting for id, decremented on partial fills
+}
+
+// Grid is a market making strategy that rests one order per price level
+// across a fixed range on the Maker Market contract, reposting the
+// opposite side of a level whenever it is filled so the grid earns the
+// spread on every round trip.
+type Grid struct {
+	log    Logger
+	market Market
+	quoter *Quoter
+	atomic Atomic
+	stats  *ProfitStats
+
+	base  common.Address
+	quote common.Address
+	pair  string
+
+	spacing GridSpacing
+	margin  *big.Int
+
+	levels []*gridLevel
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewGrid creates a new Grid quoting onto market through quoter, with
+// levelCount resting orders spaced between lower and upper (inclusive),
+// distributing quantity quote tokens evenly across the levels. Levels
+// below the current mid-price rest as buy orders and levels above it rest
+// as sell orders. It checks the available base/quote balance via atomic
+// before placing the initial orders, so the grid never overcommits
+// inventory.
+func NewGrid(log Logger, market Market, quoter *Quoter, atomic Atomic, base common.Address, quote common.Address, pair string, lower *big.Int, upper *big.Int, levelCount int, quantity *big.Int, options ...func(*Grid)) (*Grid, error) {
+
+	if levelCount < 2 {
+		return nil, fmt.Errorf("grid needs at least 2 levels, got %v", levelCount)
+	}
+	if lower.Cmp(upper) >= 0 {
+		return nil, fmt.Errorf("lower bound %v must be below upper bound %v", lower, upper)
+	}
+
+	g := Grid{
+		log:    log,
+		market: market,
+		quoter: quoter,
+		atomic: atomic,
+		base:   base,
+		quote:  quote,
+		pair:   pair,
+		margin: big.NewInt(0),
+		done:   make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(&g)
+	}
+
+	mid, err := g.mid(lower, upper)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := gridPrices(lower, upper, levelCount, g.spacing)
+	levels, err := g.buildLevels(prices, mid, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.checkInventory(levels)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.placeAll(levels)
+	if err != nil {
+		return nil, err
+	}
+	g.levels = levels
+
+	// create the cancel func before starting the execution loop, so Stop
+	// can never race against start() assigning it and call a nil func
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+	go g.start(ctx)
+
+	return &g, nil
+}
+
+// SetGridSpacing allows specifying how price levels are distributed
+// between the grid's lower and upper bound. Defaults to ArithmeticSpacing.
+func SetGridSpacing(spacing GridSpacing) func(*Grid) {
+	return func(g *Grid) {
+		g.spacing = spacing
+	}
+}
+
+// SetGridProfitSpread allows widening the price at which the opposite side
+// of a filled level is reposted, in the same units as the grid's price
+// levels, so the grid locks in at least this much margin on every round
+// trip in addition to the level spacing.
+func SetGridProfitSpread(margin *big.Int) func(*Grid) {
+	return func(g *Grid) {
+		g.margin = margin
+	}
+}
+
+// SetGridProfitStats allows specifying a ProfitStats that is updated with
+// the profit and volume of every level fill.
+func SetGridProfitStats(stats *ProfitStats) func(*Grid) {
+	return func(g *Grid) {
+		g.stats = stats
+	}
+}
+
+// mid returns a reference price used to decide which side of the range a
+// level should initially rest on: the current best bid/ask mid-price for
+// the pair, falling back to the middle of [lower, upper] if the book is
+// empty.
+func (g *Grid) mid(lower *big.Int, upper *big.Int) (*big.Int, error) {
+
+	orders, err := g.market.Orders()
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch orders for mid-price: %v", err)
+	}
+
+	book := &Book{Base: g.base, Quote: g.quote}
+	for _, order := range orders {
+		if order.BuyToken == g.base && order.SellToken == g.quote {
+			book.AddBid(order)
+		} else if order.BuyToken == g.quote && order.SellToken == g.base {
+			book.AddAsk(order)
+		}
+	}
+
+	bid, bidErr := book.HighestBid()
+	ask, askErr := book.LowestAsk()
+	if bidErr != nil || askErr != nil {
+		return new(big.Int).Rsh(new(big.Int).Add(lower, upper), 1), nil
+	}
+
+	mid := new(big.Int).Add(bid.Rate(), ask.Rate())
+	mid.Rsh(mid, 1)
+
+	return mid, nil
+}
+
+// buildLevels computes the order side and base/quote amounts for every
+// price level, splitting quantity quote tokens evenly across levels.
+func (g *Grid) buildLevels(prices []*big.Int, mid *big.Int, quantity *big.Int) ([]*gridLevel, error) {
+
+	quoteAmount := new(big.Int).Div(quantity, big.NewInt(int64(len(prices))))
+	if quoteAmount.Sign() <= 0 {
+		return nil, fmt.Errorf("quote quantity %v too small to split across %v levels", quantity, len(prices))
+	}
+
+	levels := make([]*gridLevel, 0, len(prices))
+	for _, price := range prices {
+
+		side := Buy
+		if price.Cmp(mid) > 0 {
+			side = Sell
+		}
+
+		baseAmount := new(big.Int).Div(quoteAmount, price)
+		if baseAmount.Sign() <= 0 {
+			return nil, fmt.Errorf("level price %v too high for quote amount %v", price, quoteAmount)
+		}
+
+		levels = append(levels, &gridLevel{
+			price: price,
+			side:  side,
+			base:  baseAmount,
+			quote: quoteAmount,
+		})
+	}
+
+	return levels, nil
+}
+
+// checkInventory makes sure the wallet holds enough of both base and quote
+// tokens to cover every sell and buy level before any order is placed.
+func (g *Grid) checkInventory(levels []*gridLevel) error {
+
+	baseNeeded := big.NewInt(0)
+	quoteNeeded := big.NewInt(0)
+	for _, level := range levels {
+		if level.side == Sell {
+			baseNeeded.Add(baseNeeded, level.base)
+		} else {
+			quoteNeeded.Add(quoteNeeded, level.quote)
+		}
+	}
+
+	baseAvailable, err := g.atomic.Balance(g.base)
+	if err != nil {
+		return fmt.Errorf("could not get base balance: %v", err)
+	}
+	if baseAvailable.Cmp(baseNeeded) < 0 {
+		return fmt.Errorf("insufficient base balance for grid: have %v, need %v", baseAvailable, baseNeeded)
+	}
+
+	quoteAvailable, err := g.atomic.Balance(g.quote)
+	if err != nil {
+		return fmt.Errorf("could not get quote balance: %v", err)
+	}
+	if quoteAvailable.Cmp(quoteNeeded) < 0 {
+		return fmt.Errorf("insufficient quote balance for grid: have %v, need %v", quoteAvailable, quoteNeeded)
+	}
+
+	return nil
+}
+
+// placeAll rests the initial order for every level on the market.
+func (g *Grid) placeAll(levels []*gridLevel) error {
+	for _, level := range levels {
+		id, err := g.place(level)
+		if err != nil {
+			return fmt.Errorf("could not place level at %v: %v", level.price, err)
+		}
+		level.id = id
+		level.remaining = new(big.Int).Set(level.sellAmount())
+	}
+	return nil
+}
+
+// place rests a single order for level, selling base for quote if it is a
+// sell level or quote for base if it is a buy level.
+func (g *Grid) place(level *gridLevel) (*big.Int, error) {
+	if level.side == Sell {
+		return g.quoter.Place(g.base, level.base, g.quote, level.quote)
+	}
+	return g.quoter.Place(g.quote, level.quote, g.base, level.base)
+}
+
+// sellAmount returns the amount of the token level is currently resting to
+// sell: base tokens for a sell level, quote tokens for a buy level.
+func (level *gridLevel) sellAmount() *big.Int {
+	if level.side == Sell {
+		return level.base
+	}
+	return level.quote
+}
+
+// start begins the execution loop of the grid, watching for fills of its
+// resting orders so it can repost the opposite side.
+func (g *Grid) start(ctx context.Context) {
+
+	events, err := g.market.SubscribeOrders(ctx)
+	if err != nil {
+		g.log.Criticalf("could not subscribe to order events: %v", err)
+		close(g.done)
+		return
+	}
+
+	g.run(g.done, events)
+
+	close(g.done)
+}
+
+// Stop ends the execution loop of the grid and returns after cleanly
+// shutting down.
+func (g *Grid) Stop() {
+	g.cancel()
+	g.done <- struct{}{}
+	<-g.done
+}
+
+// run processes order events until told to stop, reposting the opposite
+// side of any of the grid's levels that gets filled.
+func (g *Grid) run(done <-chan struct{}, events <-chan OrderEvent) {
+Loop:
+	for {
+		select {
+		case <-done:
+			break Loop
+		case evt := <-events:
+			if evt.Kind != EventTake {
+				continue
+			}
+			level := g.levelFor(evt.Order.ID)
+			if level == nil {
+				continue
+			}
+
+			// a partial fill leaves the order resting on-chain for the
+			// remainder; only flip the level and repost once it's gone
+			level.remaining.Sub(level.remaining, evt.Amount)
+			if level.remaining.Sign() > 0 {
+				continue
+			}
+
+			err := g.fill(level)
+			if err != nil {
+				g.log.Errorf("could not handle grid level fill: %v", err)
+			}
+		}
+	}
+}
+
+// levelFor returns the level currently resting under the given order ID,
+// or nil if it doesn't belong to this grid.
+func (g *Grid) levelFor(id *big.Int) *gridLevel {
+	for _, level := range g.levels {
+		if level.id != nil && level.id.Cmp(id) == 0 {
+			return level
+		}
+	}
+	return nil
+}
+
+// fill accounts for a filled level and reposts the opposite side at a
+// price widened by the configured profit margin, so the grid earns the
+// spread on the round trip.
+func (g *Grid) fill(level *gridLevel) error {
+
+	oldBase := new(big.Int).Set(level.base)
+	volume := new(big.Int).Set(level.sellAmount())
+
+	if level.side == Sell {
+		level.side = Buy
+		level.price = new(big.Int).Sub(level.price, g.margin)
+	} else {
+		level.side = Sell
+		level.price = new(big.Int).Add(level.price, g.margin)
+	}
+
+	level.base = new(big.Int).Div(level.quote, level.price)
+
+	if g.stats != nil {
+		baseProfit := g.profit(oldBase, level.base)
+		err := g.stats.RecordFill(g.base, g.quote, baseProfit, big.NewInt(0), volume, big.NewInt(0))
+		if err != nil {
+			g.log.Warningf("could not record grid level profit: %v", err)
+		}
+	}
+
+	id, err := g.place(level)
+	if err != nil {
+		return fmt.Errorf("could not repost level at %v: %v", level.price, err)
+	}
+	level.id = id
+	level.remaining = new(big.Int).Set(level.sellAmount())
+
+	return nil
+}
+
+// profit reports the net base realised by flipping a level from oldBase to
+// its reposted size newBase. level.quote stays fixed across the flip, so
+// the two legs' quote flows always cancel out and the round trip's entire
+// realised margin shows up as the difference between the two legs' base
+// amounts; with the default zero profit spread, oldBase equals newBase and
+// no profit is reported for a true break-even round trip.
+func (g *Grid) profit(oldBase *big.Int, newBase *big.Int) *big.Int {
+	profit := new(big.Int).Sub(newBase, oldBase)
+	profit.Abs(profit)
+	return profit
+}
+
+// gridPrices computes levelCount price levels between lower and upper
+// (inclusive), spaced according to spacing.
+func gridPrices(lower *big.Int, upper *big.Int, levelCount int, spacing GridSpacing) []*big.Int {
+
+	if spacing == GeometricSpacing {
+		return geometricPrices(lower, upper, levelCount)
+	}
+	return arithmeticPrices(lower, upper, levelCount)
+}
+
+// arithmeticPrices spaces levels evenly by absolute price difference.
+func arithmeticPrices(lower *big.Int, upper *big.Int, levelCount int) []*big.Int {
+
+	step := new(big.Int).Sub(upper, lower)
+	step.Div(step, big.NewInt(int64(levelCount-1)))
+
+	prices := make([]*big.Int, levelCount)
+	price := new(big.Int).Set(lower)
+	for i := 0; i < levelCount; i++ {
+		prices[i] = new(big.Int).Set(price)
+		price.Add(price, step)
+	}
+	prices[levelCount-1] = new(big.Int).Set(upper)
+
+	return prices
+}
+
+// geometricPrices spaces levels evenly by price ratio, using floating point
+// arithmetic for the ratio and rounding back to integer prices, so the
+// percentage gap between adjacent levels is constant.
+func geometricPrices(lower *big.Int, upper *big.Int, levelCount int) []*big.Int {
+
+	lowerFloat := new(big.Float).SetInt(lower)
+	upperFloat := new(big.Float).SetInt(upper)
+
+	ratio := new(big.Float).Quo(upperFloat, lowerFloat)
+	root := nthRoot(ratio, levelCount-1)
+
+	prices := make([]*big.Int, levelCount)
+	price := new(big.Float).Set(lowerFloat)
+	for i := 0; i < levelCount; i++ {
+		rounded, _ := price.Int(nil)
+		prices[i] = rounded
+		price.Mul(price, root)
+	}
+	prices[levelCount-1] = new(big.Int).Set(upper)
+
+	return prices
+}
+
+// nthRoot approximates the n-th root of x using Newton's method, since
+// math/big doesn't provide one for big.Float.
+func nthRoot(x *big.Float, n int) *big.Float {
+
+	if n <= 1 {
+		return new(big.Float).Set(x)
+	}
+
+	guess := new(big.Float).Sqrt(x)
+	nBig := new(big.Float).SetInt64(int64(n))
+	nMinusOne := new(big.Float).SetInt64(int64(n - 1))
+
+	for i := 0; i < 64; i++ {
+		// Newton's method for f(y) = y^n - x: y_next = ((n-1)*y + x/y^(n-1)) / n
+		pow := new(big.Float).SetInt64(1)
+		for j := 0; j < n-1; j++ {
+			pow.Mul(pow, guess)
+		}
+		next := new(big.Float).Quo(x, pow)
+		next.Add(next, new(big.Float).Mul(nMinusOne, guess))
+		next.Quo(next, nBig)
+		guess = next
+	}
+
+	return guess
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestArithmeticPrices(t *testing.T) {
+	prices := arithmeticPrices(big.NewInt(100), big.NewInt(200), 5)
+
+	want := []int64{100, 125, 150, 175, 200}
+	if len(prices) != len(want) {
+		t.Fatalf("expected %v levels, got %v", len(want), len(prices))
+	}
+	for i, w := range want {
+		if prices[i].Cmp(big.NewInt(w)) != 0 {
+			t.Errorf("level %v: expected %v, got %v", i, w, prices[i])
+		}
+	}
+}
+
+func TestGeometricPrices(t *testing.T) {
+	prices := geometricPrices(big.NewInt(100), big.NewInt(1600), 5)
+
+	if len(prices) != 5 {
+		t.Fatalf("expected 5 levels, got %v", len(prices))
+	}
+	if prices[0].Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("expected first level to equal lower bound 100, got %v", prices[0])
+	}
+	if prices[len(prices)-1].Cmp(big.NewInt(1600)) != 0 {
+		t.Errorf("expected last level to equal upper bound 1600, got %v", prices[len(prices)-1])
+	}
+
+	// with a doubling ratio between every level, the approximate prices
+	// should land close to 100, 200, 400, 800, 1600
+	want := []int64{100, 200, 400, 800, 1600}
+	for i, w := range want {
+		diff := new(big.Int).Sub(prices[i], big.NewInt(w))
+		diff.Abs(diff)
+		if diff.Cmp(big.NewInt(2)) > 0 {
+			t.Errorf("level %v: expected approximately %v, got %v", i, w, prices[i])
+		}
+	}
+}
+
+func TestGridPricesSelectsSpacing(t *testing.T) {
+	arithmetic := gridPrices(big.NewInt(100), big.NewInt(200), 3, ArithmeticSpacing)
+	if arithmetic[1].Cmp(big.NewInt(150)) != 0 {
+		t.Errorf("expected arithmetic midpoint of 150, got %v", arithmetic[1])
+	}
+
+	geometric := gridPrices(big.NewInt(100), big.NewInt(400), 3, GeometricSpacing)
+	if geometric[1].Cmp(big.NewInt(200)) != 0 {
+		t.Errorf("expected geometric midpoint of 200, got %v", geometric[1])
+	}
+}
+
+func TestGridProfitIsNetMargin(t *testing.T) {
+	g := &Grid{}
+
+	if profit := g.profit(big.NewInt(10), big.NewInt(10)); profit.Sign() != 0 {
+		t.Errorf("expected zero profit for a break-even round trip, got %v", profit)
+	}
+
+	if profit := g.profit(big.NewInt(10), big.NewInt(12)); profit.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("expected profit of 2, got %v", profit)
+	}
+
+	if profit := g.profit(big.NewInt(12), big.NewInt(10)); profit.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("expected profit of 2 regardless of flip direction, got %v", profit)
+	}
+}
+
+func TestNthRoot(t *testing.T) {
+	root := nthRoot(big.NewFloat(8), 3)
+	got, _ := root.Float64()
+	if got < 1.99 || got > 2.01 {
+		t.Errorf("expected cube root of 8 to be approximately 2, got %v", got)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"math/big"
+)
+
+// Side identifies which side of a pair a hedge trade is executed on.
+type Side int
+
+const (
+	// Buy hedges a fill by buying the base token on the centralized venue.
+	Buy Side = iota
+	// Sell hedges a fill by selling the base token on the centralized venue.
+	Sell
+)
+
+// HedgeSource is a centralized exchange that can provide a reference price
+// for a pair and immediately offset a position taken on-chain.
+type HedgeSource interface {
+
+	// BestBidAsk returns the best bid and ask for the given pair, which is
+	// used as the reference mid-price for on-chain quoting.
+	BestBidAsk(pair string) (bid *big.Int, ask *big.Int, err error)
+
+	// Hedge executes a trade on the centralized venue to offset a fill of
+	// amount base tokens on the given side of pair, using the same pair
+	// identifier passed to BestBidAsk.
+	Hedge(side Side, pair string, amount *big.Int) error
+}
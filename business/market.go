@@ -0,0 +1,37 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"context"
+
+	"github.com/awishformore/m3/model"
+)
+
+// Market is the on-chain order book that strategies such as Matcher and
+// CrossMaker read orders from.
+type Market interface {
+	Orders() ([]*model.Order, error)
+	Fills(fromBlock uint64) (<-chan model.Fill, error)
+
+	// SubscribeOrders streams LogMake, LogTake and LogKill events as they
+	// happen, letting callers maintain an order book incrementally instead
+	// of polling Orders(). Not every backend supports event filters; callers
+	// should fall back to polling if it returns an error.
+	SubscribeOrders(ctx context.Context) (<-chan OrderEvent, error)
+}
@@ -18,7 +18,7 @@
 package business
 
 import (
-	"fmt"
+	"context"
 	"math/big"
 	"time"
 
@@ -32,20 +32,29 @@ type Matcher struct {
 	log       Logger
 	atomic    Atomic
 	wallet    Wallet
+	market    Market
 	threshold *big.Int
 	refresh   time.Duration
+	breaker   *CircuitBreaker
+	stats     *ProfitStats
+	cancel    context.CancelFunc
 	done      chan struct{}
 }
 
 // NewMatcher creates a new market matcher that will try to execute trades against each other.
 func NewMatcher(log Logger, atomic Atomic, options ...func(*Matcher)) *Matcher {
 
+	// create the cancel func before starting the execution loop, so Stop
+	// can never race against start() assigning it and call a nil func
+	ctx, cancel := context.WithCancel(context.Background())
+
 	// create the channel to signal shutdown
 	m := Matcher{
 		log:       log,
 		atomic:    atomic,
 		threshold: big.NewInt(30000),
 		refresh:   time.Minute,
+		cancel:    cancel,
 		done:      make(chan struct{}),
 	}
 
@@ -55,7 +64,7 @@ func NewMatcher(log Logger, atomic Atomic, options ...func(*Matcher)) *Matcher {
 	}
 
 	// start the execution loop
-	go m.start()
+	go m.start(ctx)
 
 	return &m
 }
@@ -75,30 +84,71 @@ func SetThreshold(threshold uint64) func(*Matcher) {
 	}
 }
 
+// SetCircuitBreaker allows specifying a circuit breaker that halts arbitrage
+// when configured loss thresholds are breached.
+func SetCircuitBreaker(breaker *CircuitBreaker) func(*Matcher) {
+	return func(m *Matcher) {
+		m.breaker = breaker
+	}
+}
+
+// SetProfitStats allows specifying a ProfitStats that is updated with the
+// cost and tokens of every executed twin.
+func SetProfitStats(stats *ProfitStats) func(*Matcher) {
+	return func(m *Matcher) {
+		m.stats = stats
+	}
+}
+
+// SetWallet allows specifying the wallet used to execute twins atomically
+// on-chain and to read balances for tokens other than the ones covered by
+// atomic.
+func SetWallet(wallet Wallet) func(*Matcher) {
+	return func(m *Matcher) {
+		m.wallet = wallet
+	}
+}
+
+// SetMarket allows specifying the Maker Market to stream the order book
+// from. Required before the matcher is started.
+func SetMarket(market Market) func(*Matcher) {
+	return func(m *Matcher) {
+		m.market = market
+	}
+}
+
 // start will begin the execution loop of the matcher.
-func (m *Matcher) start() {
+func (m *Matcher) start(ctx context.Context) {
 
-	// initialize tickers
-	ticker := time.NewTicker(m.refresh)
+	// stream order book updates from the contract instead of polling on a
+	// ticker; the feed falls back to polling on its own if the backend
+	// doesn't support event subscriptions
+	feed := NewBookFeed(m.log, m.market, m.refresh)
+	updates, err := feed.Start(ctx)
+	if err != nil {
+		m.log.Criticalf("could not start book feed (%v)", err)
+		close(m.done)
+		return
+	}
 
 	// run the execution loop until it quits, with all channels providing input
 	// and output as parameters for easy testing
-	m.run(m.done, ticker.C)
+	m.run(m.done, updates)
 
 	// close channels and clean up
-	ticker.Stop()
 	close(m.done)
 }
 
 // Stop will end the execution loop of the matcher and return after cleanly
 // shutting down.
 func (m *Matcher) Stop() {
+	m.cancel()
 	m.done <- struct{}{}
 	<-m.done
 }
 
 // start will start the matcher execution loop.
-func (m *Matcher) run(done <-chan struct{}, refresh <-chan time.Time) {
+func (m *Matcher) run(done <-chan struct{}, updates <-chan []*Book) {
 Loop:
 	for {
 		select {
@@ -107,13 +157,13 @@ Loop:
 		case <-done:
 			break Loop
 
-			// on every refresh interval, get all orders and try to find arbitrage
-		case <-refresh:
+			// every time the book feed pushes a delta, try to find arbitrage
+		case books := <-updates:
 
-			// try getting all the orders from the contract
-			books, err := m.getBooks(m.atomic)
-			if err != nil {
-				m.log.Errorf("could not get orders (%v)", err)
+			// skip this round entirely if the circuit breaker is tripped,
+			// rather than tearing down the execution loop
+			if m.breaker != nil && m.breaker.Tripped() {
+				m.log.Warningf("circuit breaker tripped, skipping arbitrage")
 				continue
 			}
 
@@ -131,6 +181,21 @@ Loop:
 			changes := make(map[common.Address]*big.Int)
 			for _, twin := range twins {
 
+				// let the circuit breaker know about the twin's cost so it
+				// can trip if we're bleeding money
+				if m.breaker != nil {
+					m.breaker.Record(twin.Cost)
+				}
+
+				// let the profit stats subsystem know about the twin so it
+				// can update its per-pair and per-token breakdown
+				if m.stats != nil {
+					err := m.stats.Record(twin, twin.Cost)
+					if err != nil {
+						m.log.Warningf("could not record profit stats: %v", err)
+					}
+				}
+
 				// add cost
 				cost.Add(cost, twin.Cost)
 
@@ -171,59 +236,6 @@ Loop:
 	}
 }
 
-// getBooks returs all active orders on the given maker market in the form of
-// books that contain bids and asks. Each book represents one token pair, thus
-// granting the application support for multiple pairs.
-func (m *Matcher) getBooks(market Market) ([]*Book, error) {
-
-	// prepare empty map with books
-	bookSet := make(map[string]*Book)
-
-	// retrieve valid orders from contract
-	orders, err := market.Orders()
-	if err != nil {
-		return nil, fmt.Errorf("could not retrieve orders from market (%v)", err)
-	}
-
-	// put the orders into the respective order bookSet for their pair
-	for _, order := range orders {
-
-		// check for both pair as bid and pair as ask
-		bidPair := order.BuyToken.Hex() + order.SellToken.Hex()
-		askPair := order.SellToken.Hex() + order.BuyToken.Hex()
-
-		// check if there is a book with the bid pair and add as bid if found
-		bidBook, ok := bookSet[bidPair]
-		if ok {
-			bidBook.AddBid(order)
-			continue
-		}
-
-		// check if there is a book with the ask pair and add as ask if found
-		askBook, ok := bookSet[askPair]
-		if ok {
-			askBook.AddAsk(order)
-			continue
-		}
-
-		// if no book was found for pair or inversed pair, create bid book
-		book := Book{
-			Base:  order.BuyToken,
-			Quote: order.SellToken,
-		}
-		book.AddBid(order)
-		bookSet[bidPair] = &book
-	}
-
-	// turn the map into a slice
-	books := make([]*Book, 0, len(bookSet))
-	for _, book := range bookSet {
-		books = append(books, book)
-	}
-
-	return books, nil
-}
-
 func (m *Matcher) arbitrage(books []*Book) ([]*model.Twin, error) {
 
 	// create empty executed trades book
@@ -0,0 +1,223 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/awishformore/m3/model"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PairStats tracks the accumulated profit and volume for one token pair.
+type PairStats struct {
+	Base             common.Address
+	Quote            common.Address
+	TotalBaseProfit  *big.Int
+	TotalQuoteProfit *big.Int
+	Volume           *big.Int
+}
+
+// TradeRecord is a single executed twin, kept around in a bounded ring
+// buffer of the most recent trades.
+type TradeRecord struct {
+	At   time.Time
+	Twin *model.Twin
+	Fee  *big.Int
+}
+
+// ProfitSnapshot is the serializable form of a ProfitStats, as persisted to
+// and reloaded from a Store.
+type ProfitSnapshot struct {
+	Since  time.Time
+	Pairs  map[string]*PairStats
+	Fees   map[common.Address]*big.Int
+	Recent []TradeRecord
+}
+
+// ProfitStats tracks profit and fee statistics with a per-pair breakdown
+// across all executed twins, with a bounded history of recent trades, and
+// persists itself to a Store so restarts don't lose accounting.
+type ProfitStats struct {
+	store  Store
+	oracle FeeOracle
+	cap    int
+
+	mu     sync.Mutex
+	since  time.Time
+	pairs  map[string]*PairStats
+	fees   map[common.Address]*big.Int
+	recent []TradeRecord
+}
+
+// NewProfitStats creates a new ProfitStats backed by store, converting gas
+// costs to token amounts via oracle, and keeping up to capacity recent
+// trades. It reloads any existing snapshot from the store.
+func NewProfitStats(store Store, oracle FeeOracle, capacity int) (*ProfitStats, error) {
+
+	ps := ProfitStats{
+		store:  store,
+		oracle: oracle,
+		cap:    capacity,
+		since:  time.Now(),
+		pairs:  make(map[string]*PairStats),
+		fees:   make(map[common.Address]*big.Int),
+	}
+
+	snapshot, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != nil {
+		ps.since = snapshot.Since
+		ps.pairs = snapshot.Pairs
+		ps.fees = snapshot.Fees
+		ps.recent = snapshot.Recent
+	}
+
+	return &ps, nil
+}
+
+// Record updates the statistics for an executed twin, treating its first
+// leg as the base side and its second leg as the quote side of the pair,
+// converts gasUsed to a fee in each traded token via the configured oracle,
+// and persists the updated snapshot.
+func (ps *ProfitStats) Record(twin *model.Twin, gasUsed *big.Int) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	base := twin.First.Token
+	quote := twin.Second.Token
+
+	pair := ps.pairFor(base, quote)
+	pair.TotalBaseProfit.Add(pair.TotalBaseProfit, twin.First.Amount)
+	pair.TotalQuoteProfit.Add(pair.TotalQuoteProfit, twin.Second.Amount)
+	pair.Volume.Add(pair.Volume, new(big.Int).Abs(twin.First.Amount))
+
+	ps.recordFee(base, quote, gasUsed)
+
+	ps.recent = append(ps.recent, TradeRecord{
+		At:   time.Now(),
+		Twin: twin,
+		Fee:  gasUsed,
+	})
+	if len(ps.recent) > ps.cap {
+		ps.recent = ps.recent[len(ps.recent)-ps.cap:]
+	}
+
+	return ps.persist()
+}
+
+// RecordFill updates the per-pair statistics for a single resting order
+// fill that isn't part of an atomic Twin, such as one leg of a Grid round
+// trip, crediting baseProfit/quoteProfit and volume to the pair and
+// persisting the updated snapshot.
+func (ps *ProfitStats) RecordFill(base common.Address, quote common.Address, baseProfit *big.Int, quoteProfit *big.Int, volume *big.Int, gasUsed *big.Int) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	pair := ps.pairFor(base, quote)
+	pair.TotalBaseProfit.Add(pair.TotalBaseProfit, baseProfit)
+	pair.TotalQuoteProfit.Add(pair.TotalQuoteProfit, quoteProfit)
+	pair.Volume.Add(pair.Volume, new(big.Int).Abs(volume))
+
+	ps.recordFee(base, quote, gasUsed)
+
+	return ps.persist()
+}
+
+// pairFor returns the PairStats for the given base/quote pair, creating it
+// if it doesn't exist yet. Must be called with ps.mu held.
+func (ps *ProfitStats) pairFor(base common.Address, quote common.Address) *PairStats {
+	key := base.Hex() + quote.Hex()
+	pair, ok := ps.pairs[key]
+	if !ok {
+		pair = &PairStats{
+			Base:             base,
+			Quote:            quote,
+			TotalBaseProfit:  big.NewInt(0),
+			TotalQuoteProfit: big.NewInt(0),
+			Volume:           big.NewInt(0),
+		}
+		ps.pairs[key] = pair
+	}
+	return pair
+}
+
+// recordFee converts gasUsed to a fee in both base and quote tokens via the
+// configured oracle and adds it to the running per-token total. Must be
+// called with ps.mu held.
+func (ps *ProfitStats) recordFee(base common.Address, quote common.Address, gasUsed *big.Int) {
+	for _, token := range []common.Address{base, quote} {
+		fee, err := ps.oracle.Convert(gasUsed, token)
+		if err != nil {
+			continue
+		}
+		total, ok := ps.fees[token]
+		if !ok {
+			total = big.NewInt(0)
+			ps.fees[token] = total
+		}
+		total.Add(total, fee)
+	}
+}
+
+// Snapshot returns a copy of the current statistics for reporting, safe to
+// read after releasing the lock while Record/RecordFill keep mutating the
+// live state concurrently.
+func (ps *ProfitStats) Snapshot() ProfitSnapshot {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	pairs := make(map[string]*PairStats, len(ps.pairs))
+	for key, pair := range ps.pairs {
+		cp := *pair
+		cp.TotalBaseProfit = new(big.Int).Set(pair.TotalBaseProfit)
+		cp.TotalQuoteProfit = new(big.Int).Set(pair.TotalQuoteProfit)
+		cp.Volume = new(big.Int).Set(pair.Volume)
+		pairs[key] = &cp
+	}
+
+	fees := make(map[common.Address]*big.Int, len(ps.fees))
+	for token, fee := range ps.fees {
+		fees[token] = new(big.Int).Set(fee)
+	}
+
+	recent := make([]TradeRecord, len(ps.recent))
+	copy(recent, ps.recent)
+
+	return ProfitSnapshot{
+		Since:  ps.since,
+		Pairs:  pairs,
+		Fees:   fees,
+		Recent: recent,
+	}
+}
+
+// persist saves the current snapshot to the store. Must be called with
+// ps.mu held.
+func (ps *ProfitStats) persist() error {
+	return ps.store.Save(&ProfitSnapshot{
+		Since:  ps.since,
+		Pairs:  ps.pairs,
+		Fees:   ps.fees,
+		Recent: ps.recent,
+	})
+}
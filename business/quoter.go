@@ -0,0 +1,50 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"math/big"
+
+	"github.com/awishformore/m3/contract"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Quoter manages the lifecycle of resting orders on the Maker Market
+// contract on behalf of a quoting strategy: placing new quotes and
+// cancelling the ones that have gone stale.
+type Quoter struct {
+	market *contract.Market
+}
+
+// NewQuoter creates a new quoter bound to the given Maker Market contract.
+func NewQuoter(market *contract.Market) *Quoter {
+	return &Quoter{
+		market: market,
+	}
+}
+
+// Place submits a new resting order that sells sellAmount of sellToken for
+// at least buyAmount of buyToken, returning the on-chain order identifier.
+func (q *Quoter) Place(sellToken common.Address, sellAmount *big.Int, buyToken common.Address, buyAmount *big.Int) (*big.Int, error) {
+	return q.market.Make(sellToken, sellAmount, buyToken, buyAmount)
+}
+
+// Cancel kills a resting order so that it no longer rests on the book.
+func (q *Quoter) Cancel(id *big.Int) error {
+	return q.market.Kill(id)
+}
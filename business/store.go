@@ -17,21 +17,9 @@
 
 package business
 
-import (
-	"math/big"
-
-	"github.com/awishformore/m3/model"
-	"github.com/ethereum/go-ethereum/common"
-)
-
-type fakeWallet struct {
-	fakeContract
-}
-
-func (fw *fakeWallet) Balance(token common.Address) (*big.Int, error) {
-	return nil, nil
-}
-
-func (fw *fakeWallet) ExecuteAtomic(market Market, first *model.Order, firstSelling *big.Int, second *model.Order, secondSelling *big.Int) (*big.Int, error) {
-	return nil, nil
+// Store persists and reloads a ProfitSnapshot so that profit and fee
+// accounting survives restarts.
+type Store interface {
+	Load() (*ProfitSnapshot, error)
+	Save(snapshot *ProfitSnapshot) error
 }
@@ -0,0 +1,96 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+var boltBucket = []byte("profit_stats")
+var boltKey = []byte("snapshot")
+
+// BoltStore is a Store that persists the profit snapshot in a BoltDB
+// database file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) the BoltDB database at the given path and
+// returns a Store backed by it.
+func NewBoltStore(path string) (*BoltStore, error) {
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not open bolt database: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create bucket: %v", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Load implements Store.
+func (s *BoltStore) Load() (*ProfitSnapshot, error) {
+
+	var snapshot *ProfitSnapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBucket).Get(boltKey)
+		if data == nil {
+			return nil
+		}
+		snapshot = &ProfitSnapshot{}
+		return json.Unmarshal(data, snapshot)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not load snapshot: %v", err)
+	}
+
+	return snapshot, nil
+}
+
+// Save implements Store.
+func (s *BoltStore) Save(snapshot *ProfitSnapshot) error {
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("could not encode snapshot: %v", err)
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(boltKey, data)
+	})
+	if err != nil {
+		return fmt.Errorf("could not save snapshot: %v", err)
+	}
+
+	return nil
+}
+
+// Close releases the underlying BoltDB database handle.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2015 Max Wolter
+//
+// This file is part of M3 - Maker Market Maker.
+//
+// M3 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// M3 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with M3.  If not, see <http://www.gnu.org/licenses/>.
+
+package business
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// JSONStore is a Store that persists the profit snapshot as a single JSON
+// file on disk.
+type JSONStore struct {
+	path string
+}
+
+// NewJSONStore creates a new JSONStore that reads and writes the snapshot
+// at the given path.
+func NewJSONStore(path string) *JSONStore {
+	return &JSONStore{path: path}
+}
+
+// Load implements Store.
+func (s *JSONStore) Load() (*ProfitSnapshot, error) {
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read snapshot file: %v", err)
+	}
+
+	var snapshot ProfitSnapshot
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode snapshot: %v", err)
+	}
+
+	return &snapshot, nil
+}
+
+// Save implements Store. It writes the snapshot to a temporary file and
+// renames it over the target path, so a process kill mid-write can never
+// leave a truncated or corrupt snapshot behind for the next Load.
+func (s *JSONStore) Save(snapshot *ProfitSnapshot) error {
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("could not encode snapshot: %v", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temp snapshot file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write temp snapshot file: %v", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("could not close temp snapshot file: %v", err)
+	}
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return fmt.Errorf("could not set permissions on temp snapshot file: %v", err)
+	}
+
+	err = os.Rename(tmp.Name(), s.path)
+	if err != nil {
+		return fmt.Errorf("could not rename temp snapshot file: %v", err)
+	}
+
+	return nil
+}
@@ -1,17 +1,31 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
 	"os"
+	"os/signal"
 	"os/user"
 	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ogier/pflag"
 
+	"github.com/awishformore/m3/adaptor/hedge/binance"
+	"github.com/awishformore/m3/adaptor/hedge/kraken"
+	"github.com/awishformore/m3/adaptor/httpstats"
 	"github.com/awishformore/m3/adaptor/logger"
+	"github.com/awishformore/m3/business"
 	"github.com/awishformore/m3/contract"
 )
 
@@ -25,6 +39,23 @@ func main() {
 	testnet := pflag.BoolP("testnet", "t", true, "use testnet network")
 	level := pflag.StringP("level", "l", "INFO", "log level")
 	market := pflag.StringP("market", "m", "0x5661e7bc2403c7cc08df539e4a8e2972ec256d11", "Maker Market contract address")
+	hedgeVenue := pflag.String("hedge-venue", "", "centralized exchange to hedge fills on (binance, kraken)")
+	hedgeKey := pflag.String("hedge-key", "", "API key for the hedge venue")
+	hedgeSecret := pflag.String("hedge-secret", "", "API secret for the hedge venue")
+	hedgeBase := pflag.String("hedge-base", "", "base token address quoted by the cross maker")
+	hedgeQuote := pflag.String("hedge-quote", "", "quote token address quoted by the cross maker")
+	hedgePair := pflag.String("hedge-pair", "", "symbol of the pair to quote on the hedge venue")
+	crossSize := pflag.Uint64("cross-size", 1, "size, in base tokens, quoted on each side of the book by the cross maker")
+	statsDir := pflag.String("stats-dir", "", "directory to persist profit and fee statistics in")
+	statsAddr := pflag.String("stats-addr", ":8080", "address to serve profit and fee statistics on")
+	atomicContract := pflag.String("atomic-contract", "", "address of the atomic take contract wrapper")
+	keystoreDir := pflag.String("keystore", "", "path to the keystore directory holding the trading account")
+	passphraseFile := pflag.String("passphrase-file", "", "path to a file containing the keystore passphrase")
+	breakerLossTimes := pflag.Int("breaker-loss-times", 0, "number of consecutive losing twins that trips the circuit breaker (0 disables the check)")
+	breakerTotalLoss := pflag.Uint64("breaker-total-loss", 0, "cumulative wei lost over breaker-window that trips the circuit breaker (0 disables the check)")
+	breakerRoundLoss := pflag.Uint64("breaker-round-loss", 0, "single-twin wei loss that trips the circuit breaker (0 disables the check)")
+	breakerWindow := pflag.Duration("breaker-window", time.Hour, "rolling window over which breaker-total-loss is evaluated")
+	breakerHalt := pflag.Duration("breaker-halt", time.Hour, "how long the circuit breaker stays tripped before resuming arbitrage")
 	pflag.Parse()
 
 	// initialize logger
@@ -63,15 +94,116 @@ func main() {
 	be := backends.NewRPCBackend(conn)
 
 	// bind maker market contract
-	otc, err := contract.NewToken(common.HexToAddress(*market), be)
+	otc, err := contract.NewMarket(common.HexToAddress(*market), be)
 	if err != nil {
 		lgr.Criticalf("could not bind to market contract (%v)", err)
 		os.Exit(1)
 	}
 
-	_ = otc
+	// set up the hedge source for the configured centralized venue, if any,
+	// and start a cross maker quoting onto the market and hedging fills
+	var hedge business.HedgeSource
+	switch *hedgeVenue {
+	case "binance":
+		hedge = binance.New(*hedgeKey, *hedgeSecret)
+	case "kraken":
+		hedge = kraken.New(*hedgeKey, *hedgeSecret)
+	case "":
+		// no hedge venue configured, cross maker stays disabled
+	default:
+		lgr.Criticalf("unknown hedge venue: %v", *hedgeVenue)
+		os.Exit(1)
+	}
+	if hedge != nil {
+		head, err := be.HeaderByNumber(context.Background(), nil)
+		if err != nil {
+			lgr.Criticalf("could not fetch chain head (%v)", err)
+			os.Exit(1)
+		}
+		quoter := business.NewQuoter(otc)
+		crossMaker := business.NewCrossMaker(lgr, otc, quoter, hedge, common.HexToAddress(*hedgeBase), common.HexToAddress(*hedgeQuote), *hedgePair, business.SetCrossSize(new(big.Int).SetUint64(*crossSize)), business.SetCrossFromBlock(head.Number.Uint64()))
+		defer crossMaker.Stop()
+	}
 
-	lgr.Infof("shutting down m3 daemon")
+	// set up the wallet used to read balances and execute atomic twin
+	// trades, if a keystore was given
+	var wallet business.Wallet
+	if *keystoreDir != "" {
+		ks := keystore.NewKeyStore(*keystoreDir, keystore.StandardScryptN, keystore.StandardScryptP)
+		accounts := ks.Accounts()
+		if len(accounts) == 0 {
+			lgr.Criticalf("no accounts found in keystore %v", *keystoreDir)
+			os.Exit(1)
+		}
+
+		passphrase, err := ioutil.ReadFile(*passphraseFile)
+		if err != nil {
+			lgr.Criticalf("could not read passphrase file (%v)", err)
+			os.Exit(1)
+		}
+
+		chainID := big.NewInt(1)
+		if *testnet {
+			chainID = big.NewInt(3)
+		}
+
+		ew, err := business.NewEthWallet(lgr, be, ks, accounts[0], strings.TrimSpace(string(passphrase)), common.HexToAddress(*market), common.HexToAddress(*atomicContract), chainID)
+		if err != nil {
+			lgr.Criticalf("could not initialize wallet (%v)", err)
+			os.Exit(1)
+		}
+		wallet = ew
+	}
+
+	// set up persistent profit and fee statistics, if a stats directory was
+	// given, and serve them over HTTP
+	var stats *business.ProfitStats
+	if *statsDir != "" {
+		store := business.NewJSONStore(filepath.Join(*statsDir, "stats.json"))
+		stats, err = business.NewProfitStats(store, business.WeiFeeOracle{}, 1000)
+		if err != nil {
+			lgr.Criticalf("could not initialize profit stats (%v)", err)
+			os.Exit(1)
+		}
+		go func() {
+			err := http.ListenAndServe(*statsAddr, httpstats.NewHandler(stats))
+			if err != nil {
+				lgr.Errorf("stats server stopped (%v)", err)
+			}
+		}()
+	}
+
+	// set up the circuit breaker that halts arbitrage once the configured
+	// loss thresholds are breached; a zero threshold disables that check
+	breakerConfig := business.CircuitBreakerConfig{
+		MaximumConsecutiveLossTimes: *breakerLossTimes,
+		Window:                      *breakerWindow,
+		HaltDuration:                *breakerHalt,
+	}
+	if *breakerTotalLoss != 0 {
+		breakerConfig.MaximumConsecutiveTotalLoss = new(big.Int).SetUint64(*breakerTotalLoss)
+	}
+	if *breakerRoundLoss != 0 {
+		breakerConfig.MaximumLossPerRound = new(big.Int).SetUint64(*breakerRoundLoss)
+	}
+	breaker := business.NewCircuitBreaker(lgr, breakerConfig)
 
-	os.Exit(0)
+	// run the matcher against the configured market, executing arbitrage
+	// trades atomically through the wallet
+	if wallet != nil {
+		matcherOptions := []func(*business.Matcher){business.SetWallet(wallet), business.SetMarket(otc), business.SetCircuitBreaker(breaker)}
+		if stats != nil {
+			matcherOptions = append(matcherOptions, business.SetProfitStats(stats))
+		}
+		matcher := business.NewMatcher(lgr, wallet, matcherOptions...)
+		defer matcher.Stop()
+	}
+
+	// block until we receive a termination signal, then let the deferred
+	// Stop calls above cleanly shut down the running strategies
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+
+	lgr.Infof("shutting down m3 daemon")
 }